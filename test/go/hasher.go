@@ -0,0 +1,207 @@
+package nbrhasher
+
+import (
+	"fmt"
+	"math/big"
+
+	mimc7 "github.com/iden3/go-iden3-crypto/v2/mimc7"
+	poseidon "github.com/iden3/go-iden3-crypto/v2/poseidon"
+)
+
+// ----------------------------
+// Pluggable hash backends
+// ----------------------------
+
+// Hasher abstracts the ZK-friendly permutation used to combine a block of
+// field elements into one accumulator value. NbrArrayHasher and friends were
+// originally hard-wired to Poseidon_16 over BN254; a Hasher lets callers
+// swap in a different width or permutation (another curve's Poseidon
+// instantiation, Poseidon2, MiMC7, ...) without touching the block/padding
+// logic, which only needs to know Width().
+type Hasher interface {
+	// Width is the number of field elements consumed per call to Hash, and
+	// therefore fixes the block geometry: each block after the first holds
+	// Width()-1 neighbour slots (slot 0 carries the running accumulator or,
+	// for block 0, the degree/domain tag).
+	Width() int
+	// Hash combines exactly Width() field elements into one.
+	Hash(inputs []*big.Int) (*big.Int, error)
+}
+
+// poseidonHasher wraps github.com/iden3/go-iden3-crypto/v2/poseidon.Hash for
+// a fixed input width.
+type poseidonHasher struct {
+	width int
+}
+
+func (h poseidonHasher) Width() int { return h.width }
+
+func (h poseidonHasher) Hash(inputs []*big.Int) (*big.Int, error) {
+	if len(inputs) != h.width {
+		return nil, fmt.Errorf("poseidonHasher: expected %d inputs, got %d", h.width, len(inputs))
+	}
+	return poseidon.Hash(inputs)
+}
+
+// domainTaggedPoseidonTag seeds domainTaggedPoseidonHasher's initial state so
+// that it never collides with poseidonHasher{width: 16} on the same inputs.
+// This is plain Poseidon with a non-default initial state
+// (poseidon.HashWithState instead of poseidon.Hash's implicit zero) -- it is
+// NOT the Poseidon2 permutation (Grassi/Khovratovich/Schofnegger). It exists
+// as a second, distinguishable BN254/width-16 backend for callers (e.g. test
+// vectors) that need two backends to diverge without pulling in a different
+// permutation; go-iden3-crypto doesn't vendor Poseidon2, so a real Poseidon2
+// backend would need a separate implementation.
+var domainTaggedPoseidonTag = big.NewInt(2)
+
+// domainTaggedPoseidonHasher is the PoseidonBN254_16_DomainTagged backend;
+// see domainTaggedPoseidonTag.
+type domainTaggedPoseidonHasher struct {
+	width int
+}
+
+func (h domainTaggedPoseidonHasher) Width() int { return h.width }
+
+func (h domainTaggedPoseidonHasher) Hash(inputs []*big.Int) (*big.Int, error) {
+	if len(inputs) != h.width {
+		return nil, fmt.Errorf("domainTaggedPoseidonHasher: expected %d inputs, got %d", h.width, len(inputs))
+	}
+	return poseidon.HashWithState(inputs, domainTaggedPoseidonTag)
+}
+
+// mimc7Hasher wraps MiMC7, used where a simpler (non-Poseidon) ZK-friendly
+// permutation is preferred. MiMC7's reference implementation hashes a
+// variable-length slice under a fixed key; we pin the key to zero so that
+// mimc7Hasher.Hash is a pure function of inputs, matching the other
+// backends' contract.
+type mimc7Hasher struct {
+	width int
+}
+
+func (h mimc7Hasher) Width() int { return h.width }
+
+func (h mimc7Hasher) Hash(inputs []*big.Int) (*big.Int, error) {
+	if len(inputs) != h.width {
+		return nil, fmt.Errorf("mimc7Hasher: expected %d inputs, got %d", h.width, len(inputs))
+	}
+	return mimc7.Hash(inputs, big.NewInt(0))
+}
+
+var (
+	// PoseidonBN254_16 is the original backend NbrArrayHasher used before
+	// Hasher existed: Poseidon over BN254 with a 16-element state (1 tag +
+	// 15 neighbour slots per block).
+	PoseidonBN254_16 Hasher = poseidonHasher{width: 16}
+	// PoseidonBN254_12 is the same permutation family with a narrower,
+	// 12-element state (1 tag + 11 neighbour slots per block).
+	PoseidonBN254_12 Hasher = poseidonHasher{width: 12}
+	// PoseidonBN254_16_DomainTagged keeps PoseidonBN254_16's 16-element state
+	// but seeds a different initial state, so it never collides with
+	// PoseidonBN254_16 on the same inputs. See domainTaggedPoseidonTag --
+	// despite the similar name, this is not the Poseidon2 permutation.
+	PoseidonBN254_16_DomainTagged Hasher = domainTaggedPoseidonHasher{width: 16}
+	// MiMC7 is a narrower, 5-element-state backend for deployments that
+	// prefer MiMC7 over a Poseidon variant.
+	MiMC7 Hasher = mimc7Hasher{width: 5}
+)
+
+// hasherRegistry maps a stable string tag to a Hasher so test vectors (and
+// other serialized artifacts) can pin which backend produced them.
+var hasherRegistry = map[string]Hasher{
+	"poseidon_bn254_16":               PoseidonBN254_16,
+	"poseidon_bn254_12":               PoseidonBN254_12,
+	"poseidon_bn254_16_domain_tagged": PoseidonBN254_16_DomainTagged,
+	"mimc7":                           MiMC7,
+}
+
+// RegisterHasher adds (or overrides) a Hasher under the given tag, so
+// callers can extend the registry with custom backends without modifying
+// this file.
+func RegisterHasher(tag string, h Hasher) {
+	hasherRegistry[tag] = h
+}
+
+// GetHasher looks up a previously registered Hasher by its stable tag.
+func GetHasher(tag string) (Hasher, error) {
+	h, ok := hasherRegistry[tag]
+	if !ok {
+		return nil, fmt.Errorf("nbrhasher: unknown hasher tag %q", tag)
+	}
+	return h, nil
+}
+
+// ----------------------------
+// Backend-parameterised padding and hashing
+// ----------------------------
+
+// PadLenFor returns padLen = 1 + (h.Width()-1) * ceil(maxDegree / (h.Width()-1)),
+// generalising PadLenFromMaxDegree (which is PadLenFor(PoseidonBN254_16, maxDegree))
+// to an arbitrary backend's block width.
+func PadLenFor(h Hasher, maxDegree uint64) int {
+	blockSlots := uint64(h.Width() - 1)
+	if maxDegree == 0 {
+		// Mirror PadLenFromMaxDegree: still need room for one empty block.
+		return 1 + int(blockSlots)
+	}
+	groups := (maxDegree + blockSlots - 1) / blockSlots // ceil(maxDegree / blockSlots)
+	return int(1 + blockSlots*groups)
+}
+
+// NbrArrayHasherWith is the backend-parameterised counterpart of
+// NbrArrayHasher: it chains h.Hash calls over a padded neighbour array whose
+// layout matches PadLenFor(h, maxDegree) instead of the Poseidon_16-specific
+// PadLenFromMaxDegree.
+func NbrArrayHasherWith(h Hasher, nbrData []uint64) (*big.Int, error) {
+	width := h.Width()
+	blockSlots := width - 1
+	padLen := len(nbrData)
+	if padLen < width {
+		return nil, fmt.Errorf("NbrArrayHasherWith: nbrData len %d < width %d", padLen, width)
+	}
+	if (padLen-1)%blockSlots != 0 {
+		return nil, fmt.Errorf("NbrArrayHasherWith: invalid padLen %d (padLen-1 must be divisible by %d)", padLen, blockSlots)
+	}
+	numR := (padLen - 1) / blockSlots
+
+	block := make([]*big.Int, width)
+	for i := 0; i < width; i++ {
+		block[i] = new(big.Int).SetUint64(nbrData[i])
+	}
+	acc, err := h.Hash(block)
+	if err != nil {
+		return nil, fmt.Errorf("NbrArrayHasherWith: round 0: %w", err)
+	}
+
+	offset := width
+	for r := 1; r < numR; r++ {
+		block[0] = acc
+		for j := 1; j < width; j++ {
+			idx := offset + (j - 1)
+			if idx < padLen {
+				block[j] = new(big.Int).SetUint64(nbrData[idx])
+			} else {
+				block[j] = big.NewInt(0)
+			}
+		}
+		acc, err = h.Hash(block)
+		if err != nil {
+			return nil, fmt.Errorf("NbrArrayHasherWith: round %d: %w", r, err)
+		}
+		offset += blockSlots
+	}
+
+	return acc, nil
+}
+
+// ComputeNbrHashWith is ComputeNbrHash parameterised over a Hasher backend.
+func ComputeNbrHashWith(h Hasher, deg uint64, neighbors []uint64, maxDegree uint64) (*big.Int, error) {
+	compact := buildNbrDataCompact(neighbors)
+	compact[0] = deg
+	padLen := PadLenFor(h, maxDegree)
+	if len(compact) > padLen {
+		return nil, fmt.Errorf("ComputeNbrHashWith: compact len %d > padLen %d", len(compact), padLen)
+	}
+	padded := make([]uint64, padLen)
+	copy(padded, compact)
+	return NbrArrayHasherWith(h, padded)
+}
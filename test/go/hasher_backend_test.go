@@ -0,0 +1,133 @@
+package nbrhasher
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestPadLenFor checks the generalised padding formula against known-width
+// cases, including the PoseidonBN254_16 values PadLenFromMaxDegree returns.
+func TestPadLenFor(t *testing.T) {
+	tests := []struct {
+		hasher   Hasher
+		maxDeg   uint64
+		expected int
+	}{
+		{PoseidonBN254_16, 0, 16},
+		{PoseidonBN254_16, 15, 16},
+		{PoseidonBN254_16, 16, 31},
+		{PoseidonBN254_16, 59, 61},
+		{PoseidonBN254_12, 0, 12},
+		{PoseidonBN254_12, 11, 12},
+		{PoseidonBN254_12, 12, 23},
+		{MiMC7, 4, 5},
+		{MiMC7, 5, 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("width=%d/maxDeg=%d", tt.hasher.Width(), tt.maxDeg), func(t *testing.T) {
+			got := PadLenFor(tt.hasher, tt.maxDeg)
+			if got != tt.expected {
+				t.Errorf("PadLenFor(width=%d, %d) = %d, want %d", tt.hasher.Width(), tt.maxDeg, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestPadLenFromMaxDegreeMatchesPadLenFor pins the legacy entry point to the
+// generalised one for the default backend.
+func TestPadLenFromMaxDegreeMatchesPadLenFor(t *testing.T) {
+	for _, maxDeg := range []uint64{0, 1, 14, 15, 29, 30, 59, 60} {
+		if got, want := PadLenFromMaxDegree(maxDeg), PadLenFor(PoseidonBN254_16, maxDeg); got != want {
+			t.Errorf("PadLenFromMaxDegree(%d) = %d, want %d (PadLenFor PoseidonBN254_16)", maxDeg, got, want)
+		}
+	}
+}
+
+// TestComputeNbrHashWithMatchesLegacy confirms the new parameterised path
+// reproduces ComputeNbrHash exactly when given the same default backend, so
+// the refactor didn't change padding/layout behaviour for existing callers.
+func TestComputeNbrHashWithMatchesLegacy(t *testing.T) {
+	cases := []struct {
+		deg       uint64
+		neighbors []uint64
+		maxDegree uint64
+	}{
+		{0, nil, 59},
+		{3, []uint64{1, 3, 8}, 59},
+		{15, []uint64{2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 22, 24, 26, 28, 30}, 59},
+	}
+
+	for _, c := range cases {
+		legacy := ComputeNbrHash(c.deg, c.neighbors, c.maxDegree)
+		got, err := ComputeNbrHashWith(PoseidonBN254_16, c.deg, c.neighbors, c.maxDegree)
+		if err != nil {
+			t.Fatalf("ComputeNbrHashWith failed: %v", err)
+		}
+		if got.Cmp(legacy) != 0 {
+			t.Errorf("ComputeNbrHashWith(PoseidonBN254_16, deg=%d) = %s, want %s (legacy ComputeNbrHash)", c.deg, got.String(), legacy.String())
+		}
+	}
+}
+
+// TestBackendsDivergeOnlyByHashChoice runs identical (deg, neighbors,
+// maxDegree) through two backends that share the same width (so padding and
+// block layout are identical) and checks the outputs differ -- proving any
+// mismatch against a recorded test vector stems from the hash choice, not
+// from a padding/layout bug. It also checks both backends are internally
+// deterministic, which a layout bug (e.g. block misalignment varying per
+// call) could break independently of hash choice.
+func TestBackendsDivergeOnlyByHashChoice(t *testing.T) {
+	deg := uint64(5)
+	neighbors := []uint64{1, 3, 8, 12, 15}
+	maxDegree := uint64(15)
+
+	h1, err := ComputeNbrHashWith(PoseidonBN254_16, deg, neighbors, maxDegree)
+	if err != nil {
+		t.Fatalf("PoseidonBN254_16: %v", err)
+	}
+	h2, err := ComputeNbrHashWith(PoseidonBN254_16_DomainTagged, deg, neighbors, maxDegree)
+	if err != nil {
+		t.Fatalf("PoseidonBN254_16_DomainTagged: %v", err)
+	}
+
+	if h1.Cmp(h2) == 0 {
+		t.Fatalf("PoseidonBN254_16 and PoseidonBN254_16_DomainTagged produced the same root %s for identical inputs; expected the differing initial state to diverge", h1.String())
+	}
+
+	h1Again, err := ComputeNbrHashWith(PoseidonBN254_16, deg, neighbors, maxDegree)
+	if err != nil {
+		t.Fatalf("PoseidonBN254_16 (repeat): %v", err)
+	}
+	if h1.Cmp(h1Again) != 0 {
+		t.Errorf("PoseidonBN254_16 is not deterministic: got %s then %s for identical inputs", h1.String(), h1Again.String())
+	}
+
+	h2Again, err := ComputeNbrHashWith(PoseidonBN254_16_DomainTagged, deg, neighbors, maxDegree)
+	if err != nil {
+		t.Fatalf("PoseidonBN254_16_DomainTagged (repeat): %v", err)
+	}
+	if h2.Cmp(h2Again) != 0 {
+		t.Errorf("PoseidonBN254_16_DomainTagged is not deterministic: got %s then %s for identical inputs", h2.String(), h2Again.String())
+	}
+}
+
+// TestGetHasherRegistry exercises the string-tag registry used to pin a
+// backend in serialized test-vector metadata.
+func TestGetHasherRegistry(t *testing.T) {
+	tags := []string{"poseidon_bn254_16", "poseidon_bn254_12", "poseidon_bn254_16_domain_tagged", "mimc7"}
+	for _, tag := range tags {
+		h, err := GetHasher(tag)
+		if err != nil {
+			t.Errorf("GetHasher(%q) returned error: %v", tag, err)
+			continue
+		}
+		if h.Width() <= 0 {
+			t.Errorf("GetHasher(%q).Width() = %d, want > 0", tag, h.Width())
+		}
+	}
+
+	if _, err := GetHasher("not-a-real-tag"); err == nil {
+		t.Error("GetHasher(\"not-a-real-tag\") expected an error, got nil")
+	}
+}
@@ -0,0 +1,260 @@
+package nbrhasher
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	poseidon "github.com/iden3/go-iden3-crypto/v2/poseidon"
+)
+
+// ----------------------------
+// Delta-encoded wire format
+// ----------------------------
+//
+// Neighbour ids are stored sorted and strictly ascending, which makes them a
+// good fit for delta + varint encoding: the padded [deg, u0, ..., u_{padLen-2}]
+// layout used for hashing wastes bandwidth on zero padding and on large,
+// mostly-redundant ids. The wire format below is only ever used for
+// transport/storage; PadNbrData/NbrArrayHasher are unaffected.
+//
+// Layout:
+//
+//	byte 0:       version (currently 1)
+//	varint:       maxDegree
+//	varint:       deg
+//	varint * deg: u0, u1-u0, u2-u1, ..., u_{deg-1}-u_{deg-2}
+const encodingVersion = 1
+
+// Encode serialises (deg, neighbors, maxDegree) into the compact
+// delta+varint wire format described above. neighbors must already be
+// sorted ascending (Encode does not itself validate this; use
+// ValidateEncoded on the result, or ValidateNeighbors-style checks upstream,
+// if the caller's input isn't already known-good).
+func Encode(deg uint64, neighbors []uint64, maxDegree uint64) []byte {
+	buf := make([]byte, 0, 1+binary.MaxVarintLen64*(2+len(neighbors)))
+	buf = append(buf, encodingVersion)
+	buf = appendUvarint(buf, maxDegree)
+	buf = appendUvarint(buf, deg)
+
+	prev := uint64(0)
+	for _, u := range neighbors {
+		buf = appendUvarint(buf, u-prev)
+		prev = u
+	}
+	return buf
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], x)
+	return append(buf, tmp[:n]...)
+}
+
+// Decode parses the wire format produced by Encode. It errors on an
+// unrecognised version, a truncated/malformed varint, or trailing bytes
+// left over after the expected deg varints are consumed.
+func Decode(buf []byte) (deg uint64, neighbors []uint64, maxDegree uint64, err error) {
+	if len(buf) < 1 {
+		return 0, nil, 0, fmt.Errorf("nbrhasher: Decode: empty buffer")
+	}
+	if buf[0] != encodingVersion {
+		return 0, nil, 0, fmt.Errorf("nbrhasher: Decode: unsupported version %d", buf[0])
+	}
+	offset := 1
+
+	maxDegree, n, err := readUvarint(buf, offset)
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("nbrhasher: Decode: maxDegree: %w", err)
+	}
+	offset += n
+
+	deg, n, err = readUvarint(buf, offset)
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("nbrhasher: Decode: deg: %w", err)
+	}
+	offset += n
+
+	neighbors = make([]uint64, deg)
+	prev := uint64(0)
+	for i := uint64(0); i < deg; i++ {
+		delta, n, err := readUvarint(buf, offset)
+		if err != nil {
+			return 0, nil, 0, fmt.Errorf("nbrhasher: Decode: neighbor %d: %w", i, err)
+		}
+		offset += n
+		if prev+delta < prev {
+			return 0, nil, 0, fmt.Errorf("nbrhasher: Decode: neighbor %d: delta %d overflows uint64 accumulating onto %d", i, delta, prev)
+		}
+		prev += delta
+		neighbors[i] = prev
+	}
+
+	if offset != len(buf) {
+		return 0, nil, 0, fmt.Errorf("nbrhasher: Decode: %d trailing byte(s) after %d neighbor(s)", len(buf)-offset, deg)
+	}
+
+	return deg, neighbors, maxDegree, nil
+}
+
+func readUvarint(buf []byte, offset int) (uint64, int, error) {
+	x, n := binary.Uvarint(buf[offset:])
+	if n == 0 {
+		return 0, 0, fmt.Errorf("buffer too short")
+	}
+	if n < 0 {
+		return 0, 0, fmt.Errorf("varint overflows uint64")
+	}
+	return x, n, nil
+}
+
+// HashEncoded decodes buf and computes its Poseidon neighbour hash in one
+// pass, filling each 16-element block directly from the varint stream
+// instead of first materialising the padded []uint64 array.
+func HashEncoded(buf []byte) (*big.Int, error) {
+	if len(buf) < 1 {
+		return nil, fmt.Errorf("nbrhasher: HashEncoded: empty buffer")
+	}
+	if buf[0] != encodingVersion {
+		return nil, fmt.Errorf("nbrhasher: HashEncoded: unsupported version %d", buf[0])
+	}
+	offset := 1
+
+	maxDegree, n, err := readUvarint(buf, offset)
+	if err != nil {
+		return nil, fmt.Errorf("nbrhasher: HashEncoded: maxDegree: %w", err)
+	}
+	offset += n
+
+	deg, n, err := readUvarint(buf, offset)
+	if err != nil {
+		return nil, fmt.Errorf("nbrhasher: HashEncoded: deg: %w", err)
+	}
+	offset += n
+
+	if deg > maxDegree {
+		return nil, fmt.Errorf("nbrhasher: HashEncoded: deg %d exceeds maxDegree %d", deg, maxDegree)
+	}
+
+	padLen := PadLenFromMaxDegree(maxDegree)
+	if padLen < 16 {
+		// PadLenFromMaxDegree wraps for a maxDegree large enough to overflow
+		// its internal uint64 arithmetic (e.g. near math.MaxUint64), which
+		// would otherwise produce numR == 0 below and return a nil root as
+		// if hashing had succeeded. Mirrors the padLen < width guard
+		// NbrArrayHasher/NbrArrayHasherWith already have.
+		return nil, fmt.Errorf("nbrhasher: HashEncoded: maxDegree %d produces invalid padLen %d", maxDegree, padLen)
+	}
+	numR := (padLen - 1) / 15
+
+	block := make([]*big.Int, 16)
+	var acc *big.Int
+	prev := uint64(0)
+	consumed := uint64(0)
+
+	for r := 0; r < numR; r++ {
+		if r == 0 {
+			block[0] = new(big.Int).SetUint64(deg)
+		} else {
+			block[0] = acc
+		}
+		for j := 1; j < 16; j++ {
+			if consumed < deg {
+				delta, n, err := readUvarint(buf, offset)
+				if err != nil {
+					return nil, fmt.Errorf("nbrhasher: HashEncoded: neighbor %d: %w", consumed, err)
+				}
+				offset += n
+				if prev+delta < prev {
+					return nil, fmt.Errorf("nbrhasher: HashEncoded: neighbor %d: delta %d overflows uint64 accumulating onto %d", consumed, delta, prev)
+				}
+				prev += delta
+				block[j] = new(big.Int).SetUint64(prev)
+				consumed++
+			} else {
+				block[j] = big.NewInt(0)
+			}
+		}
+		acc, err = poseidon.Hash(block)
+		if err != nil {
+			return nil, fmt.Errorf("nbrhasher: HashEncoded: poseidon.Hash round %d: %w", r, err)
+		}
+	}
+
+	if offset != len(buf) {
+		return nil, fmt.Errorf("nbrhasher: HashEncoded: %d trailing byte(s) after %d neighbor(s)", len(buf)-offset, deg)
+	}
+
+	return acc, nil
+}
+
+// ValidateEncoded checks that buf is a well-formed, canonical encoding: a
+// recognised version, minimal (non-overlong) varints throughout, deg <=
+// maxDegree, and strictly ascending neighbour ids. It exists so that two
+// different byte strings can never decode to the same node -- callers that
+// persist or gossip encoded neighbour sets should run untrusted input
+// through ValidateEncoded before treating it as canonical.
+func ValidateEncoded(buf []byte) error {
+	if len(buf) < 1 {
+		return fmt.Errorf("nbrhasher: ValidateEncoded: empty buffer")
+	}
+	if buf[0] != encodingVersion {
+		return fmt.Errorf("nbrhasher: ValidateEncoded: unsupported version %d", buf[0])
+	}
+	offset := 1
+
+	maxDegree, n, err := readCanonicalUvarint(buf, offset)
+	if err != nil {
+		return fmt.Errorf("nbrhasher: ValidateEncoded: maxDegree: %w", err)
+	}
+	offset += n
+
+	deg, n, err := readCanonicalUvarint(buf, offset)
+	if err != nil {
+		return fmt.Errorf("nbrhasher: ValidateEncoded: deg: %w", err)
+	}
+	offset += n
+
+	if deg > maxDegree {
+		return fmt.Errorf("nbrhasher: ValidateEncoded: deg %d exceeds maxDegree %d", deg, maxDegree)
+	}
+
+	prev := uint64(0)
+	for i := uint64(0); i < deg; i++ {
+		delta, n, err := readCanonicalUvarint(buf, offset)
+		if err != nil {
+			return fmt.Errorf("nbrhasher: ValidateEncoded: neighbor %d: %w", i, err)
+		}
+		offset += n
+		if i > 0 && delta == 0 {
+			return fmt.Errorf("nbrhasher: ValidateEncoded: neighbor %d repeats neighbor %d (ids must be strictly ascending)", i, i-1)
+		}
+		if prev+delta < prev {
+			return fmt.Errorf("nbrhasher: ValidateEncoded: neighbor %d: delta %d overflows uint64 accumulating onto %d", i, delta, prev)
+		}
+		prev += delta
+	}
+
+	if offset != len(buf) {
+		return fmt.Errorf("nbrhasher: ValidateEncoded: %d trailing byte(s) after %d neighbor(s)", len(buf)-offset, deg)
+	}
+
+	return nil
+}
+
+// readCanonicalUvarint is readUvarint plus a check that the varint was
+// encoded with the minimal number of bytes, rejecting overlong encodings
+// (e.g. 0x80 0x00 for the value 0) that would let distinct byte strings
+// decode to the same value.
+func readCanonicalUvarint(buf []byte, offset int) (uint64, int, error) {
+	x, n, err := readUvarint(buf, offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	var tmp [binary.MaxVarintLen64]byte
+	minimal := binary.PutUvarint(tmp[:], x)
+	if minimal != n {
+		return 0, 0, fmt.Errorf("non-canonical varint: used %d byte(s), minimal encoding is %d", n, minimal)
+	}
+	return x, n, nil
+}
@@ -0,0 +1,180 @@
+package nbrhasher
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		deg       uint64
+		neighbors []uint64
+		maxDegree uint64
+	}{
+		{"empty", 0, nil, 59},
+		{"single", 1, []uint64{25}, 59},
+		{"clustered", 5, []uint64{1000, 1001, 1002, 1003, 1004}, 59},
+		{"sparse", 3, []uint64{1, 9999, 1000000}, 59},
+		{"full_block", 15, []uint64{2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 22, 24, 26, 28, 30}, 59},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := Encode(tt.deg, tt.neighbors, tt.maxDegree)
+
+			deg, neighbors, maxDegree, err := Decode(buf)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if deg != tt.deg {
+				t.Errorf("deg = %d, want %d", deg, tt.deg)
+			}
+			if maxDegree != tt.maxDegree {
+				t.Errorf("maxDegree = %d, want %d", maxDegree, tt.maxDegree)
+			}
+			if len(neighbors) != len(tt.neighbors) {
+				t.Fatalf("neighbors len = %d, want %d", len(neighbors), len(tt.neighbors))
+			}
+			for i := range neighbors {
+				if neighbors[i] != tt.neighbors[i] {
+					t.Errorf("neighbors[%d] = %d, want %d", i, neighbors[i], tt.neighbors[i])
+				}
+			}
+
+			if err := ValidateEncoded(buf); err != nil {
+				t.Errorf("ValidateEncoded rejected a valid encoding: %v", err)
+			}
+		})
+	}
+}
+
+func TestHashEncodedMatchesComputeNbrHash(t *testing.T) {
+	tests := []struct {
+		deg       uint64
+		neighbors []uint64
+		maxDegree uint64
+	}{
+		{0, nil, 59},
+		{3, []uint64{1, 3, 8}, 59},
+		{15, []uint64{2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 22, 24, 26, 28, 30}, 59},
+		{30, []uint64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100, 110, 120, 130, 140, 150, 160, 170, 180, 190, 200, 210, 220, 230, 240, 250, 260, 270, 280, 290, 300}, 59},
+	}
+
+	for _, tt := range tests {
+		buf := Encode(tt.deg, tt.neighbors, tt.maxDegree)
+		got, err := HashEncoded(buf)
+		if err != nil {
+			t.Fatalf("HashEncoded failed: %v", err)
+		}
+		want := ComputeNbrHash(tt.deg, tt.neighbors, tt.maxDegree)
+		if got.Cmp(want) != 0 {
+			t.Errorf("HashEncoded(deg=%d) = %s, want %s (ComputeNbrHash)", tt.deg, got.String(), want.String())
+		}
+	}
+}
+
+func TestValidateEncodedRejectsNonAscending(t *testing.T) {
+	// Build an encoding with a repeated neighbor id (delta 0 after the first
+	// entry). Encode does not validate its input, so it emits this for a
+	// non-strictly-ascending list; a hostile/corrupted buffer might too.
+	buf := Encode(2, []uint64{5, 5}, 59)
+	if err := ValidateEncoded(buf); err == nil {
+		t.Error("ValidateEncoded accepted a buffer with a repeated neighbor id")
+	}
+}
+
+func TestValidateEncodedRejectsDegExceedingMaxDegree(t *testing.T) {
+	buf := Encode(5, []uint64{1, 2, 3, 4, 5}, 59)
+	buf[1] = 2 // overwrite the maxDegree varint with a too-small value
+	if err := ValidateEncoded(buf); err == nil {
+		t.Error("ValidateEncoded accepted deg > maxDegree")
+	}
+}
+
+func TestValidateEncodedRejectsOverlongVarint(t *testing.T) {
+	// version=1, maxDegree=59 (canonical, 1 byte), deg encoded as an
+	// overlong 2-byte varint for the value 0 (0x80 0x00) instead of the
+	// canonical 1-byte form.
+	buf := []byte{encodingVersion, 59, 0x80, 0x00}
+	if err := ValidateEncoded(buf); err == nil {
+		t.Error("ValidateEncoded accepted a non-canonical (overlong) varint")
+	}
+}
+
+func TestDecodeRejectsUnknownVersion(t *testing.T) {
+	buf := Encode(0, nil, 59)
+	buf[0] = 0xFF
+	if _, _, _, err := Decode(buf); err == nil {
+		t.Error("Decode accepted an unrecognised version byte")
+	}
+}
+
+func TestDecodeRejectsTrailingBytes(t *testing.T) {
+	buf := Encode(1, []uint64{7}, 59)
+	buf = append(buf, 0x01)
+	if _, _, _, err := Decode(buf); err == nil {
+		t.Error("Decode accepted a buffer with trailing bytes")
+	}
+}
+
+// encodeRawVarint builds a version=1 buffer with explicit maxDegree/deg/delta
+// varints, bypassing Encode's u-prev subtraction so a delta near
+// math.MaxUint64 can be placed directly onto the wire.
+func encodeRawVarint(maxDegree, deg uint64, deltas []uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	buf := []byte{encodingVersion}
+	n := binary.PutUvarint(tmp[:], maxDegree)
+	buf = append(buf, tmp[:n]...)
+	n = binary.PutUvarint(tmp[:], deg)
+	buf = append(buf, tmp[:n]...)
+	for _, d := range deltas {
+		n = binary.PutUvarint(tmp[:], d)
+		buf = append(buf, tmp[:n]...)
+	}
+	return buf
+}
+
+// TestDecodeRejectsDeltaOverflow and TestValidateEncodedRejectsDeltaOverflow
+// cover a buffer whose accumulated neighbor id (prev+delta) wraps a uint64,
+// which Encode would never produce from a valid ascending neighbor list but
+// a hostile/corrupted buffer might.
+func TestDecodeRejectsDeltaOverflow(t *testing.T) {
+	buf := encodeRawVarint(math.MaxUint64, 2, []uint64{10, math.MaxUint64 - 5})
+	if _, _, _, err := Decode(buf); err == nil {
+		t.Error("Decode accepted a delta that overflows uint64 when accumulated")
+	}
+}
+
+func TestValidateEncodedRejectsDeltaOverflow(t *testing.T) {
+	buf := encodeRawVarint(math.MaxUint64, 2, []uint64{10, math.MaxUint64 - 5})
+	if err := ValidateEncoded(buf); err == nil {
+		t.Error("ValidateEncoded accepted a delta that overflows uint64 when accumulated")
+	}
+}
+
+func TestHashEncodedRejectsDeltaOverflow(t *testing.T) {
+	// maxDegree is kept small here so HashEncoded gets past its padLen check
+	// and actually reads the overflowing delta.
+	buf := encodeRawVarint(59, 2, []uint64{10, math.MaxUint64 - 5})
+	if _, err := HashEncoded(buf); err == nil {
+		t.Error("HashEncoded accepted a delta that overflows uint64 when accumulated")
+	}
+}
+
+// TestHashEncodedRejectsHugeMaxDegree covers deg=0 with a maxDegree large
+// enough to overflow PadLenFromMaxDegree's internal arithmetic (a buffer
+// ValidateEncoded accepts, since deg <= maxDegree trivially holds and there
+// are no neighbor varints to check). HashEncoded must error rather than
+// return a nil *big.Int as if hashing had succeeded.
+func TestHashEncodedRejectsHugeMaxDegree(t *testing.T) {
+	buf := encodeRawVarint(math.MaxUint64, 0, nil)
+	if err := ValidateEncoded(buf); err != nil {
+		t.Fatalf("test setup bug: ValidateEncoded rejected the buffer: %v", err)
+	}
+	got, err := HashEncoded(buf)
+	if err == nil {
+		t.Fatalf("HashEncoded(deg=0, maxDegree=MaxUint64) = %v, <nil>, want an error", got)
+	}
+}
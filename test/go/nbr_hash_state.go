@@ -0,0 +1,164 @@
+package nbrhasher
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	poseidon "github.com/iden3/go-iden3-crypto/v2/poseidon"
+)
+
+// NbrHashState is a convenience wrapper around the padded neighbour array
+// and Poseidon accumulators ComputeNbrHash would otherwise recompute from
+// scratch. It is NOT an incremental/complexity-improving API: a mutation at
+// any block always costs a full O(numR) replay, the same order as just
+// calling ComputeNbrHash again on the mutated neighbour list. Don't reach
+// for NbrHashState expecting a partial-replay speedup; reach for it only
+// when Root()-after-Insert/Remove/Replace is more convenient to call than
+// re-deriving (deg, neighbors) and calling ComputeNbrHash yourself.
+//
+// Why there's no partial replay: block 0's input embeds the current degree
+// as a domain tag, and every Insert/Remove changes the degree by one -- so
+// block 0's accumulator is never reusable across a mutation, and since each
+// later block's input is the previous block's accumulator, a changed block
+// 0 forces every later block to be replayed too. Insert/Remove therefore
+// always call recomputeFrom(0): there is no mutation that can skip block 0.
+// (Replace is Remove followed by Insert, so it pays this cost twice.)
+// Fixing this would mean changing which block (if any) carries the degree
+// tag -- a change to NbrArrayHasher's/ComputeNbrHash's output format, which
+// would break hash compatibility with every existing caller (including the
+// Circom circuit mirror) and is out of scope here.
+//
+// The padded array's length is fixed by maxDegree (see PadLenFromMaxDegree)
+// regardless of the current degree, so inserting or removing a neighbour
+// never changes the number of blocks (numR) -- it only changes how much of
+// the tail is zero padding.
+type NbrHashState struct {
+	maxDegree uint64
+	padded    []uint64   // [deg, u0, ..., u_{padLen-2}]
+	accs      []*big.Int // accs[b] = Poseidon_16 output of block b, chained
+}
+
+// deg returns the current degree, i.e. padded[0].
+func (s *NbrHashState) deg() uint64 { return s.padded[0] }
+
+func (s *NbrHashState) setDeg(d uint64) { s.padded[0] = d }
+
+// neighbors returns the slice view of the currently active (non-padding)
+// neighbour ids, backed by the state's padded array.
+func (s *NbrHashState) neighbors() []uint64 {
+	return s.padded[1 : 1+s.deg()]
+}
+
+// NewNbrHashState builds an NbrHashState from a degree/neighbour list the
+// same way ComputeNbrHash does, additionally caching every block's
+// accumulator. See the NbrHashState doc comment: this cache does not let a
+// later mutation replay less than the full block range.
+func NewNbrHashState(deg uint64, neighbors []uint64, maxDegree uint64) *NbrHashState {
+	compact := buildNbrDataCompact(neighbors)
+	compact[0] = deg
+	padded := padNbrData(compact, maxDegree)
+
+	numR := (len(padded) - 1) / 15
+	s := &NbrHashState{
+		maxDegree: maxDegree,
+		padded:    padded,
+		accs:      make([]*big.Int, numR),
+	}
+	s.recomputeFrom(0)
+	return s
+}
+
+// Root returns the current neighbour-array hash (the last block's
+// accumulator).
+func (s *NbrHashState) Root() *big.Int {
+	return s.accs[len(s.accs)-1]
+}
+
+// recomputeFrom replays blocks [fromBlock, numR) from their current padded
+// contents, using accs[fromBlock-1] (or the degree tag, for block 0) as the
+// starting accumulator.
+func (s *NbrHashState) recomputeFrom(fromBlock int) {
+	numR := len(s.accs)
+	block := make([]*big.Int, 16)
+
+	for b := fromBlock; b < numR; b++ {
+		if b == 0 {
+			block[0] = new(big.Int).SetUint64(s.padded[0]) // deg
+		} else {
+			block[0] = s.accs[b-1]
+		}
+		offset := 1 + 15*b
+		for j := 1; j < 16; j++ {
+			block[j] = new(big.Int).SetUint64(s.padded[offset+(j-1)])
+		}
+		acc, err := poseidon.Hash(block)
+		if err != nil {
+			panic(fmt.Errorf("NbrHashState: poseidon.Hash block %d: %w", b, err))
+		}
+		s.accs[b] = acc
+	}
+}
+
+// Insert adds u to the neighbour set, preserving ascending order. It always
+// replays from block 0 (see the NbrHashState doc comment for why).
+func (s *NbrHashState) Insert(u uint64) error {
+	nbrs := s.neighbors()
+	pos := sort.Search(len(nbrs), func(i int) bool { return nbrs[i] >= u })
+	if pos < len(nbrs) && nbrs[pos] == u {
+		return fmt.Errorf("NbrHashState.Insert: %d is already a neighbour", u)
+	}
+	deg := s.deg()
+	if deg+1 > s.maxDegree {
+		return fmt.Errorf("NbrHashState.Insert: degree %d would exceed maxDegree %d", deg+1, s.maxDegree)
+	}
+
+	// Shift padded[pos+1 .. deg] right by one slot to make room at pos.
+	for idx := int(deg); idx > pos; idx-- {
+		s.padded[idx+1] = s.padded[idx]
+	}
+	s.padded[pos+1] = u
+	s.setDeg(deg + 1)
+
+	s.recomputeFrom(0)
+	return nil
+}
+
+// Remove deletes u from the neighbour set. It always replays from block 0
+// (see the NbrHashState doc comment for why).
+func (s *NbrHashState) Remove(u uint64) error {
+	nbrs := s.neighbors()
+	pos := sort.Search(len(nbrs), func(i int) bool { return nbrs[i] >= u })
+	if pos >= len(nbrs) || nbrs[pos] != u {
+		return fmt.Errorf("NbrHashState.Remove: %d is not a neighbour", u)
+	}
+	deg := s.deg()
+
+	// u sits at array index pos+1; shift padded[pos+2 .. deg] left by one
+	// slot to close the gap it leaves behind.
+	for idx := pos + 1; idx < int(deg); idx++ {
+		s.padded[idx] = s.padded[idx+1]
+	}
+	s.padded[deg] = 0
+	s.setDeg(deg - 1)
+
+	s.recomputeFrom(0)
+	return nil
+}
+
+// Replace swaps old for new, preserving ascending order. It is implemented
+// as Remove(old) followed by Insert(new), so it replays from block 0 twice.
+func (s *NbrHashState) Replace(old, new uint64) error {
+	if err := s.Remove(old); err != nil {
+		return fmt.Errorf("NbrHashState.Replace: %w", err)
+	}
+	if err := s.Insert(new); err != nil {
+		// Best-effort rollback so a failed Replace doesn't leave the state
+		// missing `old` with no replacement.
+		if rollbackErr := s.Insert(old); rollbackErr != nil {
+			return fmt.Errorf("NbrHashState.Replace: %w, and rollback failed: %v", err, rollbackErr)
+		}
+		return fmt.Errorf("NbrHashState.Replace: %w", err)
+	}
+	return nil
+}
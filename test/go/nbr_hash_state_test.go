@@ -0,0 +1,239 @@
+package nbrhasher
+
+import (
+	"testing"
+)
+
+func TestNbrHashStateMatchesComputeNbrHash(t *testing.T) {
+	deg := uint64(5)
+	neighbors := []uint64{1, 3, 8, 12, 15}
+	maxDegree := uint64(59)
+
+	s := NewNbrHashState(deg, neighbors, maxDegree)
+	want := ComputeNbrHash(deg, neighbors, maxDegree)
+	if s.Root().Cmp(want) != 0 {
+		t.Fatalf("NewNbrHashState root = %s, want %s", s.Root().String(), want.String())
+	}
+}
+
+func TestNbrHashStateInsert(t *testing.T) {
+	maxDegree := uint64(59)
+	s := NewNbrHashState(3, []uint64{1, 3, 8}, maxDegree)
+
+	if err := s.Insert(5); err != nil {
+		t.Fatalf("Insert(5) failed: %v", err)
+	}
+
+	want := ComputeNbrHash(4, []uint64{1, 3, 5, 8}, maxDegree)
+	if s.Root().Cmp(want) != 0 {
+		t.Errorf("after Insert(5), root = %s, want %s", s.Root().String(), want.String())
+	}
+
+	if err := s.Insert(5); err == nil {
+		t.Error("Insert(5) a second time should have failed (duplicate neighbour)")
+	}
+}
+
+func TestNbrHashStateInsertExceedingMaxDegree(t *testing.T) {
+	s := NewNbrHashState(2, []uint64{1, 2}, 2)
+	if err := s.Insert(3); err == nil {
+		t.Error("Insert should have failed once degree would exceed maxDegree")
+	}
+}
+
+func TestNbrHashStateRemove(t *testing.T) {
+	maxDegree := uint64(59)
+	s := NewNbrHashState(4, []uint64{1, 3, 5, 8}, maxDegree)
+
+	if err := s.Remove(5); err != nil {
+		t.Fatalf("Remove(5) failed: %v", err)
+	}
+
+	want := ComputeNbrHash(3, []uint64{1, 3, 8}, maxDegree)
+	if s.Root().Cmp(want) != 0 {
+		t.Errorf("after Remove(5), root = %s, want %s", s.Root().String(), want.String())
+	}
+
+	if err := s.Remove(5); err == nil {
+		t.Error("Remove(5) a second time should have failed (not a neighbour)")
+	}
+}
+
+func TestNbrHashStateReplace(t *testing.T) {
+	maxDegree := uint64(59)
+	s := NewNbrHashState(3, []uint64{1, 3, 8}, maxDegree)
+
+	if err := s.Replace(3, 6); err != nil {
+		t.Fatalf("Replace(3, 6) failed: %v", err)
+	}
+
+	want := ComputeNbrHash(3, []uint64{1, 6, 8}, maxDegree)
+	if s.Root().Cmp(want) != 0 {
+		t.Errorf("after Replace(3, 6), root = %s, want %s", s.Root().String(), want.String())
+	}
+}
+
+func TestNbrHashStateReplaceMissingOldRollsBack(t *testing.T) {
+	maxDegree := uint64(59)
+	s := NewNbrHashState(3, []uint64{1, 3, 8}, maxDegree)
+	before := s.Root()
+
+	if err := s.Replace(99, 6); err == nil {
+		t.Fatal("Replace(99, 6) should have failed (99 is not a neighbour)")
+	}
+	if s.Root().Cmp(before) != 0 {
+		t.Errorf("Replace rollback left root = %s, want unchanged %s", s.Root().String(), before.String())
+	}
+}
+
+// TestNbrHashStateInsertOutsideBlockZeroMatchesComputeNbrHash exercises
+// Insert at positions that land in block 0, a middle block, and the tail
+// block of a degree-59 node, checking each resulting root against a
+// from-scratch ComputeNbrHash. TestNbrHashStateInsert alone can't catch a
+// stale block-0 accumulator, since its 3-neighbour input never places a
+// neighbour outside block 0.
+func TestNbrHashStateInsertOutsideBlockZeroMatchesComputeNbrHash(t *testing.T) {
+	maxDegree := uint64(60)
+
+	tests := []struct {
+		name string
+		u    uint64 // lands in block 0, 1 (middle), or 3 (tail) of makeDegree59Neighbors
+	}{
+		{"head_block0", 1},
+		{"middle_block1", 59},
+		{"tail_lastBlock", 119},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := makeDegree59Neighbors()
+			s := NewNbrHashState(uint64(len(base)), base, maxDegree)
+
+			if err := s.Insert(tt.u); err != nil {
+				t.Fatalf("Insert(%d) failed: %v", tt.u, err)
+			}
+
+			wantNeighbors := insertSorted(base, tt.u)
+			want := ComputeNbrHash(uint64(len(wantNeighbors)), wantNeighbors, maxDegree)
+			if s.Root().Cmp(want) != 0 {
+				t.Errorf("after Insert(%d), root = %s, want %s (ComputeNbrHash from scratch)", tt.u, s.Root().String(), want.String())
+			}
+		})
+	}
+}
+
+// TestNbrHashStateRemoveOutsideBlockZeroMatchesComputeNbrHash is the Remove
+// counterpart of TestNbrHashStateInsertOutsideBlockZeroMatchesComputeNbrHash.
+func TestNbrHashStateRemoveOutsideBlockZeroMatchesComputeNbrHash(t *testing.T) {
+	maxDegree := uint64(60)
+	base := makeDegree59Neighbors() // [2, 4, ..., 118], 59 entries
+
+	tests := []struct {
+		name string
+		u    uint64
+	}{
+		{"head_block0", base[0]},
+		{"middle_block1", base[20]},
+		{"tail_lastBlock", base[58]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewNbrHashState(uint64(len(base)), base, maxDegree)
+
+			if err := s.Remove(tt.u); err != nil {
+				t.Fatalf("Remove(%d) failed: %v", tt.u, err)
+			}
+
+			wantNeighbors := removeSorted(base, tt.u)
+			want := ComputeNbrHash(uint64(len(wantNeighbors)), wantNeighbors, maxDegree)
+			if s.Root().Cmp(want) != 0 {
+				t.Errorf("after Remove(%d), root = %s, want %s (ComputeNbrHash from scratch)", tt.u, s.Root().String(), want.String())
+			}
+		})
+	}
+}
+
+// insertSorted returns a new slice with u inserted into sorted, preserving
+// ascending order.
+func insertSorted(sorted []uint64, u uint64) []uint64 {
+	out := make([]uint64, 0, len(sorted)+1)
+	inserted := false
+	for _, v := range sorted {
+		if !inserted && u < v {
+			out = append(out, u)
+			inserted = true
+		}
+		out = append(out, v)
+	}
+	if !inserted {
+		out = append(out, u)
+	}
+	return out
+}
+
+// removeSorted returns a new slice with the first occurrence of u removed.
+func removeSorted(sorted []uint64, u uint64) []uint64 {
+	out := make([]uint64, 0, len(sorted)-1)
+	for _, v := range sorted {
+		if v == u {
+			continue
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func makeDegree59Neighbors() []uint64 {
+	out := make([]uint64, 59)
+	for i := range out {
+		out[i] = uint64((i + 1) * 2) // even, ascending, leaves odd gaps to insert into
+	}
+	return out
+}
+
+func benchmarkInsert(b *testing.B, u uint64) {
+	maxDegree := uint64(60)
+	base := makeDegree59Neighbors()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		s := NewNbrHashState(uint64(len(base)), base, maxDegree)
+		b.StartTimer()
+
+		if err := s.Insert(u); err != nil {
+			b.Fatalf("Insert(%d) failed: %v", u, err)
+		}
+	}
+}
+
+// BenchmarkNbrHashStateInsertHead inserts before every existing neighbour.
+// Since Insert always changes the degree, and block 0's domain tag is the
+// degree, every Insert replays from block 0 regardless of where the new
+// neighbour lands (see the NbrHashState doc comment) -- so this, InsertMiddle,
+// and InsertTail below are expected to cost about the same.
+func BenchmarkNbrHashStateInsertHead(b *testing.B) {
+	benchmarkInsert(b, 1)
+}
+
+// BenchmarkNbrHashStateInsertMiddle inserts into the middle block.
+func BenchmarkNbrHashStateInsertMiddle(b *testing.B) {
+	benchmarkInsert(b, 59)
+}
+
+// BenchmarkNbrHashStateInsertTail inserts after every existing neighbour.
+func BenchmarkNbrHashStateInsertTail(b *testing.B) {
+	benchmarkInsert(b, 119)
+}
+
+// BenchmarkComputeNbrHashDegree59 is the non-incremental baseline: a full
+// re-hash from scratch, for comparison against the benchmarks above.
+func BenchmarkComputeNbrHashDegree59(b *testing.B) {
+	maxDegree := uint64(60)
+	neighbors := makeDegree59Neighbors()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ComputeNbrHash(uint64(len(neighbors)), neighbors, maxDegree)
+	}
+}
@@ -0,0 +1,299 @@
+package nbrhasher
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	poseidon "github.com/iden3/go-iden3-crypto/v2/poseidon"
+)
+
+// ----------------------------
+// Hash mode selection
+// ----------------------------
+
+// HashMode selects how a neighbour array is combined into a single root:
+// the legacy sequential chain (NbrArrayHasher) or the parallel Merkle tree
+// (NbrTreeHasher) added below. Callers pick one explicitly; the two modes
+// are not interchangeable since they produce different roots for the same
+// padded array.
+type HashMode int
+
+const (
+	// HashModeChain is the original O(numR) sequential Poseidon_16 chain.
+	HashModeChain HashMode = iota
+	// HashModeTree is the Merkle-tree layout: leaves are hashed independently
+	// and combined pairwise, enabling parallel leaf computation and
+	// O(log numR) path recomputation on a single-block mutation.
+	HashModeTree
+)
+
+// ----------------------------
+// Tree-shaped (Merkle) neighbour hashing
+// ----------------------------
+//
+// NbrTreeHash(v) algorithm:
+//
+// - Input: PaddedNbrData_G(v) of length padLen = 1 + 15*numR, exactly as
+//   used by NbrArrayHasher.
+//
+// - For b = 0..numR-1:
+//     Bb = [tag_b, u_{15b}, ..., u_{15b+14}]   (16 field elements)
+//     where tag_b = deg if b == 0, else 0 (a domain tag marking "not the
+//     head block" so blocks cannot be permuted without changing the hash)
+//     leaf_b = Poseidon_16(Bb)
+//
+// - Combine leaves pairwise bottom-up:
+//     level 0: leaf_0, leaf_1, ..., leaf_{numR-1}
+//     level k+1: Poseidon_2([level_k[2i], level_k[2i+1]]) for each pair;
+//     an odd node at the end of a level is duplicated (paired with itself)
+//     rather than promoted, so every level combines via Poseidon_2.
+//
+// - Output: the single remaining node (the root), after ceil(log2 numR)
+//   levels.
+//
+// Circom mirror: a `NbrTreeHasher(maxDegree)` template should instantiate
+// numR Poseidon_16 leaf components identically to `NbrArrayHasher`'s block
+// circuit (reusing the same domain-tag convention), then a binary tree of
+// Poseidon_2 components with the last-node-duplicated rule above. The
+// `NbrTreeUpdate` Merkle path (siblings + blockIndex bits) mirrors the
+// witness a circuit would need to update a single leaf without
+// re-deriving the whole tree.
+
+// nbrTreeLeaf computes leaf_b = Poseidon_16([tag, u_{15b}..u_{15b+14}]) from
+// a padded neighbour array.
+func nbrTreeLeaf(nbrData []uint64, b int) (*big.Int, error) {
+	block := make([]*big.Int, 16)
+	if b == 0 {
+		block[0] = new(big.Int).SetUint64(nbrData[0]) // deg
+	} else {
+		block[0] = big.NewInt(0) // domain tag: "not the head block"
+	}
+	offset := 1 + 15*b
+	for j := 1; j < 16; j++ {
+		idx := offset + (j - 1)
+		if idx < len(nbrData) {
+			block[j] = new(big.Int).SetUint64(nbrData[idx])
+		} else {
+			block[j] = big.NewInt(0)
+		}
+	}
+	return poseidon.Hash(block)
+}
+
+// HashBlocksParallel computes every leaf_b of a padded neighbour array
+// concurrently and returns them in block order (leaves[b] == leaf_b).
+// It panics on malformed input, matching NbrArrayHasher's conventions.
+func HashBlocksParallel(nbrData []uint64) []*big.Int {
+	padLen := len(nbrData)
+	if padLen < 16 {
+		panic(fmt.Sprintf("HashBlocksParallel: nbrData len %d < 16", padLen))
+	}
+	if (padLen-1)%15 != 0 {
+		panic(fmt.Sprintf("HashBlocksParallel: invalid padLen %d (padLen-1 must be divisible by 15)", padLen))
+	}
+	numR := (padLen - 1) / 15
+
+	leaves := make([]*big.Int, numR)
+	errs := make([]error, numR)
+
+	var wg sync.WaitGroup
+	wg.Add(numR)
+	for b := 0; b < numR; b++ {
+		go func(b int) {
+			defer wg.Done()
+			leaves[b], errs[b] = nbrTreeLeaf(nbrData, b)
+		}(b)
+	}
+	wg.Wait()
+
+	for b, err := range errs {
+		if err != nil {
+			panic(fmt.Errorf("HashBlocksParallel: poseidon.Hash leaf %d: %w", b, err))
+		}
+	}
+	return leaves
+}
+
+// nbrTreeCombine folds the leaves level by level up to the single root,
+// duplicating a trailing odd node at each level rather than promoting it.
+func nbrTreeCombine(level []*big.Int) (*big.Int, error) {
+	for len(level) > 1 {
+		next := make([]*big.Int, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			left := level[i]
+			right := left
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			node, err := poseidon.Hash([]*big.Int{left, right})
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, node)
+		}
+		level = next
+	}
+	return level[0], nil
+}
+
+// NbrTreeHasher expects a *padded* neighbour array (see NbrArrayHasher) and
+// returns the Merkle root over its 15-neighbour blocks, as described above.
+func NbrTreeHasher(nbrData []uint64) *big.Int {
+	leaves := HashBlocksParallel(nbrData)
+	root, err := nbrTreeCombine(leaves)
+	if err != nil {
+		panic(fmt.Errorf("NbrTreeHasher: combining leaves: %w", err))
+	}
+	return root
+}
+
+// NbrTreeHash is the tree-mode counterpart of ComputeNbrHash: it builds the
+// padded array from (deg, neighbors, maxDegree) and returns the Merkle root.
+func NbrTreeHash(deg uint64, neighbors []uint64, maxDegree uint64) *big.Int {
+	compact := buildNbrDataCompact(neighbors)
+	compact[0] = deg
+	padded := padNbrData(compact, maxDegree)
+	return NbrTreeHasher(padded)
+}
+
+// TreePathStep is one level of a path produced by NbrTreePath. SelfPaired
+// means this level's node has no true sibling (a trailing odd node) and was
+// combined with a duplicate of itself; in that case Sibling is nil and
+// applyTreePath must re-duplicate whatever leaf it's carrying at this level,
+// rather than reuse a cached hash -- a self-paired sibling is the *same
+// node* as the one being updated, so it changes along with it, unlike a
+// true sibling which stays fixed across the update.
+type TreePathStep struct {
+	Sibling    *big.Int
+	SelfPaired bool
+}
+
+// NbrTreePath returns the sibling (or self-pairing marker) at every level
+// from leaf `block` up to (but not including) the root of
+// NbrTreeHasher(nbrData)'s tree, in the order NbrTreeUpdate expects for
+// cachedPath. A caller holding this path (and the block's current/previous
+// contents) can apply NbrTreeUpdate without re-hashing the other numR-1
+// leaves.
+func NbrTreePath(nbrData []uint64, block int) []TreePathStep {
+	level := HashBlocksParallel(nbrData)
+	if block < 0 || block >= len(level) {
+		panic(fmt.Sprintf("NbrTreePath: block %d out of range [0, %d)", block, len(level)))
+	}
+
+	path := make([]TreePathStep, 0)
+	idx := block
+	for len(level) > 1 {
+		siblingIdx := idx ^ 1 // idx's pair partner within this level
+		if siblingIdx >= len(level) {
+			path = append(path, TreePathStep{SelfPaired: true}) // trailing odd node: paired with itself
+		} else {
+			path = append(path, TreePathStep{Sibling: level[siblingIdx]})
+		}
+
+		next := make([]*big.Int, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			left := level[i]
+			right := left
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			node, err := poseidon.Hash([]*big.Int{left, right})
+			if err != nil {
+				panic(fmt.Errorf("NbrTreePath: poseidon.Hash level node: %w", err))
+			}
+			next = append(next, node)
+		}
+		level = next
+		idx /= 2
+	}
+	return path
+}
+
+// applyTreePath recombines a leaf up through cachedPath the same way
+// NbrTreeHasher's pairwise combine does, returning the resulting root. At a
+// SelfPaired step, it pairs the live leaf with itself instead of reusing a
+// cached sibling hash -- the cached hash is a snapshot of the *old* leaf, and
+// a self-paired "sibling" is just the node itself, so it must track whichever
+// leaf (old or new) is currently being recombined.
+func applyTreePath(leaf *big.Int, block int, cachedPath []TreePathStep) (*big.Int, error) {
+	node := leaf
+	idx := block
+	for _, step := range cachedPath {
+		var pair []*big.Int
+		switch {
+		case step.SelfPaired:
+			pair = []*big.Int{node, node}
+		case idx%2 == 0:
+			pair = []*big.Int{node, step.Sibling}
+		default:
+			pair = []*big.Int{step.Sibling, node}
+		}
+		var err error
+		node, err = poseidon.Hash(pair)
+		if err != nil {
+			return nil, err
+		}
+		idx /= 2
+	}
+	return node, nil
+}
+
+// NbrTreeUpdate recomputes a tree root after a single block's contents
+// change, touching only the O(log numR) nodes on that block's path instead
+// of re-hashing every leaf.
+//
+// block is the index (0-based) of the block that changed. oldVals and
+// newVals are each the 16-element block contents ([tag, u_{15b}..u_{15b+14}])
+// before and after the mutation. cachedPath holds the sibling (or
+// self-pairing marker) at every level from the leaf up to (but not
+// including) the root -- see NbrTreePath, which produces it in the expected
+// order. A SelfPaired step means that level has no true sibling, so both
+// the old- and new-root recombination re-duplicate the live leaf at that
+// level rather than reuse a stale cached hash. Before applying the update,
+// NbrTreeUpdate recombines oldVals with cachedPath and checks the result
+// against oldRoot; a mismatch means cachedPath (or oldVals) is stale or
+// corrupted, and NbrTreeUpdate returns an error rather than silently
+// producing a root that doesn't follow from oldRoot.
+func NbrTreeUpdate(oldRoot *big.Int, cachedPath []TreePathStep, block int, oldVals, newVals []*big.Int) (*big.Int, error) {
+	if len(oldVals) != 16 || len(newVals) != 16 {
+		return nil, fmt.Errorf("NbrTreeUpdate: block contents must have length 16, got %d/%d", len(oldVals), len(newVals))
+	}
+
+	oldLeaf, err := poseidon.Hash(oldVals)
+	if err != nil {
+		return nil, fmt.Errorf("NbrTreeUpdate: poseidon.Hash old leaf: %w", err)
+	}
+	recomputedOldRoot, err := applyTreePath(oldLeaf, block, cachedPath)
+	if err != nil {
+		return nil, fmt.Errorf("NbrTreeUpdate: recombining old leaf: %w", err)
+	}
+	if recomputedOldRoot.Cmp(oldRoot) != 0 {
+		return nil, fmt.Errorf("NbrTreeUpdate: cachedPath/oldVals reconstruct root %s, not the given oldRoot %s; refusing to apply update", recomputedOldRoot.String(), oldRoot.String())
+	}
+
+	newLeaf, err := poseidon.Hash(newVals)
+	if err != nil {
+		return nil, fmt.Errorf("NbrTreeUpdate: poseidon.Hash new leaf: %w", err)
+	}
+	newRoot, err := applyTreePath(newLeaf, block, cachedPath)
+	if err != nil {
+		return nil, fmt.Errorf("NbrTreeUpdate: recombining new leaf: %w", err)
+	}
+	return newRoot, nil
+}
+
+// ComputeNbrHashMode computes a node's neighbour hash under the given
+// HashMode, dispatching to ComputeNbrHash (HashModeChain) or NbrTreeHash
+// (HashModeTree) so callers can pick chain vs. tree via a single value
+// instead of branching on which function to call themselves.
+func ComputeNbrHashMode(mode HashMode, deg uint64, neighbors []uint64, maxDegree uint64) (*big.Int, error) {
+	switch mode {
+	case HashModeChain:
+		return ComputeNbrHash(deg, neighbors, maxDegree), nil
+	case HashModeTree:
+		return NbrTreeHash(deg, neighbors, maxDegree), nil
+	default:
+		return nil, fmt.Errorf("nbrhasher: unknown HashMode %d", mode)
+	}
+}
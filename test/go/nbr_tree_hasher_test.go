@@ -0,0 +1,307 @@
+package nbrhasher
+
+import (
+	"math/big"
+	"testing"
+
+	poseidon "github.com/iden3/go-iden3-crypto/v2/poseidon"
+)
+
+// handComputeTreeRoot re-derives the expected root independently of
+// NbrTreeHasher: it hashes each block directly (mirroring nbrTreeLeaf) and
+// combines levels pairwise, duplicating a trailing odd node, exactly as the
+// doc comment above NbrTreeHasher describes.
+func handComputeTreeRoot(t *testing.T, deg uint64, neighbors []uint64, maxDegree uint64) *big.Int {
+	t.Helper()
+
+	compact := buildNbrDataCompact(neighbors)
+	compact[0] = deg
+	padded := padNbrData(compact, maxDegree)
+
+	numR := (len(padded) - 1) / 15
+	level := make([]*big.Int, numR)
+	for b := 0; b < numR; b++ {
+		block := make([]*big.Int, 16)
+		if b == 0 {
+			block[0] = new(big.Int).SetUint64(padded[0])
+		} else {
+			block[0] = big.NewInt(0)
+		}
+		offset := 1 + 15*b
+		for j := 1; j < 16; j++ {
+			block[j] = new(big.Int).SetUint64(padded[offset+(j-1)])
+		}
+		leaf, err := poseidon.Hash(block)
+		if err != nil {
+			t.Fatalf("handComputeTreeRoot: poseidon.Hash leaf %d: %v", b, err)
+		}
+		level[b] = leaf
+	}
+
+	for len(level) > 1 {
+		next := make([]*big.Int, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			left := level[i]
+			right := left
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			node, err := poseidon.Hash([]*big.Int{left, right})
+			if err != nil {
+				t.Fatalf("handComputeTreeRoot: poseidon.Hash combine: %v", err)
+			}
+			next = append(next, node)
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// TestNbrTreeHashMatchesHandComputedParity checks NbrTreeHash against an
+// independently hand-rolled combine for numR = 1..4 (maxDegree 15/30/45/60),
+// specifically covering the numR=3 case where the leaf level has an odd
+// number of nodes and the trailing one must be duplicated rather than
+// promoted as-is.
+func TestNbrTreeHashMatchesHandComputedParity(t *testing.T) {
+	tests := []struct {
+		name      string
+		numR      int
+		maxDegree uint64
+	}{
+		{"numR1", 1, 15},
+		{"numR2", 2, 30},
+		{"numR3_oddDuplication", 3, 45},
+		{"numR4", 4, 60},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			neighbors := make([]uint64, tt.maxDegree)
+			for i := range neighbors {
+				neighbors[i] = uint64(i + 1)
+			}
+			deg := tt.maxDegree
+
+			want := handComputeTreeRoot(t, deg, neighbors, tt.maxDegree)
+			got := NbrTreeHash(deg, neighbors, tt.maxDegree)
+			if got.Cmp(want) != 0 {
+				t.Errorf("NbrTreeHash(numR=%d) = %s, want %s (hand-computed)", tt.numR, got.String(), want.String())
+			}
+		})
+	}
+}
+
+// TestNbrTreeHashOddDuplicationMatters confirms the numR=3 duplication rule
+// actually changes the root: combining the 3 leaves as [L0,L1] then
+// [combine,L2,L2] (duplicated) must differ from naively promoting L2
+// unduplicated into the next level paired with a zero, since those are two
+// different trees.
+func TestNbrTreeHashOddDuplicationMatters(t *testing.T) {
+	maxDegree := uint64(45) // numR = 3
+	neighbors := make([]uint64, maxDegree)
+	for i := range neighbors {
+		neighbors[i] = uint64(i + 1)
+	}
+	deg := maxDegree
+
+	compact := buildNbrDataCompact(neighbors)
+	compact[0] = deg
+	padded := padNbrData(compact, maxDegree)
+	leaves := HashBlocksParallel(padded)
+	if len(leaves) != 3 {
+		t.Fatalf("test setup bug: expected 3 leaves, got %d", len(leaves))
+	}
+
+	combined01, err := poseidon.Hash([]*big.Int{leaves[0], leaves[1]})
+	if err != nil {
+		t.Fatalf("poseidon.Hash: %v", err)
+	}
+	duplicated2, err := poseidon.Hash([]*big.Int{leaves[2], leaves[2]}) // leaves[2] paired with itself
+	if err != nil {
+		t.Fatalf("poseidon.Hash: %v", err)
+	}
+	wantRoot, err := poseidon.Hash([]*big.Int{combined01, duplicated2})
+	if err != nil {
+		t.Fatalf("poseidon.Hash: %v", err)
+	}
+
+	zeroPaired2, err := poseidon.Hash([]*big.Int{leaves[2], big.NewInt(0)}) // leaves[2] paired with a zero
+	if err != nil {
+		t.Fatalf("poseidon.Hash: %v", err)
+	}
+	zeroPairRoot, err := poseidon.Hash([]*big.Int{combined01, zeroPaired2})
+	if err != nil {
+		t.Fatalf("poseidon.Hash: %v", err)
+	}
+
+	got := NbrTreeHash(deg, neighbors, maxDegree)
+	if got.Cmp(wantRoot) != 0 {
+		t.Errorf("NbrTreeHash with odd-node duplication = %s, want %s", got.String(), wantRoot.String())
+	}
+	if got.Cmp(zeroPairRoot) == 0 {
+		t.Errorf("NbrTreeHash matched the zero-padded-pair tree; duplication rule isn't being applied")
+	}
+}
+
+// TestComputeNbrHashModeDispatch checks ComputeNbrHashMode routes to
+// ComputeNbrHash and NbrTreeHash respectively, and rejects an unknown mode.
+func TestComputeNbrHashModeDispatch(t *testing.T) {
+	deg := uint64(5)
+	neighbors := []uint64{1, 3, 8, 12, 15}
+	maxDegree := uint64(59)
+
+	chainGot, err := ComputeNbrHashMode(HashModeChain, deg, neighbors, maxDegree)
+	if err != nil {
+		t.Fatalf("ComputeNbrHashMode(HashModeChain) error: %v", err)
+	}
+	if chainWant := ComputeNbrHash(deg, neighbors, maxDegree); chainGot.Cmp(chainWant) != 0 {
+		t.Errorf("ComputeNbrHashMode(HashModeChain) = %s, want %s", chainGot.String(), chainWant.String())
+	}
+
+	treeGot, err := ComputeNbrHashMode(HashModeTree, deg, neighbors, maxDegree)
+	if err != nil {
+		t.Fatalf("ComputeNbrHashMode(HashModeTree) error: %v", err)
+	}
+	if treeWant := NbrTreeHash(deg, neighbors, maxDegree); treeGot.Cmp(treeWant) != 0 {
+		t.Errorf("ComputeNbrHashMode(HashModeTree) = %s, want %s", treeGot.String(), treeWant.String())
+	}
+
+	if _, err := ComputeNbrHashMode(HashMode(99), deg, neighbors, maxDegree); err == nil {
+		t.Error("ComputeNbrHashMode(99) should have failed for an unknown mode")
+	}
+}
+
+// TestNbrTreeUpdateMatchesFromScratchRecompute mutates one neighbour of a
+// degree-59 node (landing in a middle block), derives cachedPath via
+// NbrTreePath against the *old* tree, and checks NbrTreeUpdate's root
+// matches a from-scratch NbrTreeHash of the mutated neighbour set.
+func TestNbrTreeUpdateMatchesFromScratchRecompute(t *testing.T) {
+	maxDegree := uint64(60) // numR = 4
+	oldNeighbors := makeDegree59Neighbors()
+	deg := uint64(len(oldNeighbors))
+
+	oldCompact := buildNbrDataCompact(oldNeighbors)
+	oldCompact[0] = deg
+	oldPadded := padNbrData(oldCompact, maxDegree)
+
+	block := 1 // middle block
+	oldRoot := NbrTreeHasher(oldPadded)
+	cachedPath := NbrTreePath(oldPadded, block)
+
+	oldVals := make([]*big.Int, 16)
+	oldVals[0] = big.NewInt(0) // block 1's domain tag
+	offset := 1 + 15*block
+	for j := 1; j < 16; j++ {
+		oldVals[j] = new(big.Int).SetUint64(oldPadded[offset+(j-1)])
+	}
+
+	// Mutate one neighbour within that block's range.
+	newNeighbors := append([]uint64(nil), oldNeighbors...)
+	mutateIdx := offset // first neighbour index covered by block 1
+	newNeighbors[mutateIdx-1] = newNeighbors[mutateIdx-1] + 1000
+
+	newCompact := buildNbrDataCompact(newNeighbors)
+	newCompact[0] = deg
+	newPadded := padNbrData(newCompact, maxDegree)
+
+	newVals := make([]*big.Int, 16)
+	newVals[0] = big.NewInt(0)
+	for j := 1; j < 16; j++ {
+		newVals[j] = new(big.Int).SetUint64(newPadded[offset+(j-1)])
+	}
+
+	gotRoot, err := NbrTreeUpdate(oldRoot, cachedPath, block, oldVals, newVals)
+	if err != nil {
+		t.Fatalf("NbrTreeUpdate failed: %v", err)
+	}
+
+	want := NbrTreeHash(deg, newNeighbors, maxDegree)
+	if gotRoot.Cmp(want) != 0 {
+		t.Errorf("NbrTreeUpdate root = %s, want %s (from-scratch NbrTreeHash)", gotRoot.String(), want.String())
+	}
+}
+
+// TestNbrTreeUpdateMatchesFromScratchRecomputeSelfPairedBlock is the
+// duplication-case counterpart of
+// TestNbrTreeUpdateMatchesFromScratchRecompute: at maxDegree=45 (numR=3),
+// block 2 is the trailing node duplicated against itself at level 0 of the
+// tree, rather than combined with a true sibling. NbrTreeUpdate must
+// re-duplicate the *new* leaf at that level, not replay a cached hash of the
+// old leaf, or the resulting root silently diverges from a from-scratch
+// recompute.
+func TestNbrTreeUpdateMatchesFromScratchRecomputeSelfPairedBlock(t *testing.T) {
+	maxDegree := uint64(45) // numR = 3
+	oldNeighbors := make([]uint64, maxDegree)
+	for i := range oldNeighbors {
+		oldNeighbors[i] = uint64(i + 1)
+	}
+	deg := uint64(len(oldNeighbors))
+
+	oldCompact := buildNbrDataCompact(oldNeighbors)
+	oldCompact[0] = deg
+	oldPadded := padNbrData(oldCompact, maxDegree)
+
+	block := 2 // trailing block of a 3-leaf tree: self-paired, not a true sibling
+	oldRoot := NbrTreeHasher(oldPadded)
+	cachedPath := NbrTreePath(oldPadded, block)
+
+	offset := 1 + 15*block
+	oldVals := make([]*big.Int, 16)
+	oldVals[0] = big.NewInt(0)
+	for j := 1; j < 16; j++ {
+		oldVals[j] = new(big.Int).SetUint64(oldPadded[offset+(j-1)])
+	}
+
+	newNeighbors := append([]uint64(nil), oldNeighbors...)
+	newNeighbors[offset-1] = newNeighbors[offset-1] + 1000
+
+	newCompact := buildNbrDataCompact(newNeighbors)
+	newCompact[0] = deg
+	newPadded := padNbrData(newCompact, maxDegree)
+
+	newVals := make([]*big.Int, 16)
+	newVals[0] = big.NewInt(0)
+	for j := 1; j < 16; j++ {
+		newVals[j] = new(big.Int).SetUint64(newPadded[offset+(j-1)])
+	}
+
+	gotRoot, err := NbrTreeUpdate(oldRoot, cachedPath, block, oldVals, newVals)
+	if err != nil {
+		t.Fatalf("NbrTreeUpdate failed: %v", err)
+	}
+
+	want := NbrTreeHash(deg, newNeighbors, maxDegree)
+	if gotRoot.Cmp(want) != 0 {
+		t.Errorf("NbrTreeUpdate root (self-paired block) = %s, want %s (from-scratch NbrTreeHash)", gotRoot.String(), want.String())
+	}
+}
+
+// TestNbrTreeUpdateRejectsStaleOldRoot confirms NbrTreeUpdate refuses to
+// apply a mutation when oldRoot doesn't actually follow from cachedPath and
+// oldVals, rather than silently returning an unrelated root.
+func TestNbrTreeUpdateRejectsStaleOldRoot(t *testing.T) {
+	maxDegree := uint64(60)
+	oldNeighbors := makeDegree59Neighbors()
+	deg := uint64(len(oldNeighbors))
+
+	oldCompact := buildNbrDataCompact(oldNeighbors)
+	oldCompact[0] = deg
+	oldPadded := padNbrData(oldCompact, maxDegree)
+
+	block := 1
+	cachedPath := NbrTreePath(oldPadded, block)
+
+	oldVals := make([]*big.Int, 16)
+	oldVals[0] = big.NewInt(0)
+	offset := 1 + 15*block
+	for j := 1; j < 16; j++ {
+		oldVals[j] = new(big.Int).SetUint64(oldPadded[offset+(j-1)])
+	}
+	newVals := append([]*big.Int(nil), oldVals...)
+	newVals[1] = new(big.Int).Add(newVals[1], big.NewInt(1))
+
+	staleRoot := big.NewInt(12345) // does not match cachedPath/oldVals
+	if _, err := NbrTreeUpdate(staleRoot, cachedPath, block, oldVals, newVals); err == nil {
+		t.Error("NbrTreeUpdate should have rejected a stale/incorrect oldRoot")
+	}
+}
@@ -0,0 +1,235 @@
+package nbrhasher
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+// boundaryMaxDegrees are the maxDegree pairs that sit right below a
+// PadLenFromMaxDegree block transition (14/15, 29/30, 59/60: each pair
+// shares numR, and the next value up adds a block), plus 120 as a larger
+// multi-block case. Table-driven tests against pre-generated JSON vectors
+// can't exercise these edges on their own, since the vectors were only ever
+// generated for one maxDegree.
+var boundaryMaxDegrees = []uint64{14, 15, 29, 30, 59, 60, 120}
+
+// randomNeighborSet deterministically derives a distinct, sorted, strictly
+// ascending neighbour set (and its degree) from seed, for use as fuzz/quick
+// input. It never produces more than maxDegree neighbours.
+func randomNeighborSet(seed int64, maxDegree uint64) (uint64, []uint64) {
+	r := rand.New(rand.NewSource(seed))
+	deg := uint64(0)
+	if maxDegree > 0 {
+		deg = uint64(r.Intn(int(maxDegree) + 1))
+	}
+
+	neighbors := make([]uint64, deg)
+	cur := uint64(0)
+	for i := range neighbors {
+		cur += uint64(r.Intn(5)) + 1 // strictly increasing
+		neighbors[i] = cur
+	}
+	return deg, neighbors
+}
+
+// TestNbrHashDeterminism is property (1): hashing the same input twice
+// yields the same *big.Int.
+func TestNbrHashDeterminism(t *testing.T) {
+	prop := func(seed int64, maxDegIdx uint8) bool {
+		maxDegree := boundaryMaxDegrees[int(maxDegIdx)%len(boundaryMaxDegrees)]
+		deg, neighbors := randomNeighborSet(seed, maxDegree)
+
+		h1 := ComputeNbrHash(deg, neighbors, maxDegree)
+		h2 := ComputeNbrHash(deg, neighbors, maxDegree)
+		return h1.Cmp(h2) == 0
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestNbrHashPaddingInvariance is property (2): ComputeNbrHash(deg, nbrs, M1)
+// equals ComputeNbrHash(deg, nbrs, M2) whenever M1 and M2 resolve to the same
+// numR (here: the three boundary pairs just below a block transition).
+func TestNbrHashPaddingInvariance(t *testing.T) {
+	pairs := [][2]uint64{{14, 15}, {29, 30}, {59, 60}}
+
+	for _, pair := range pairs {
+		m1, m2 := pair[0], pair[1]
+		if PadLenFromMaxDegree(m1) != PadLenFromMaxDegree(m2) {
+			t.Fatalf("test setup bug: PadLenFromMaxDegree(%d) != PadLenFromMaxDegree(%d)", m1, m2)
+		}
+
+		prop := func(seed int64) bool {
+			deg, neighbors := randomNeighborSet(seed, m1) // m1 is the smaller of the pair
+			h1 := ComputeNbrHash(deg, neighbors, m1)
+			h2 := ComputeNbrHash(deg, neighbors, m2)
+			return h1.Cmp(h2) == 0
+		}
+		if err := quick.Check(prop, &quick.Config{MaxCount: 200}); err != nil {
+			t.Errorf("maxDegree pair (%d, %d): %v", m1, m2, err)
+		}
+	}
+
+	// And the converse: a maxDegree that actually changes numR (14 vs 29)
+	// must not collide by coincidence for the same small input.
+	deg, neighbors := randomNeighborSet(1, 14)
+	if PadLenFromMaxDegree(14) == PadLenFromMaxDegree(29) {
+		t.Fatal("test setup bug: expected different padLen for maxDegree 14 vs 29")
+	}
+	h14 := ComputeNbrHash(deg, neighbors, 14)
+	h29 := ComputeNbrHash(deg, neighbors, 29)
+	if h14.Cmp(h29) == 0 {
+		t.Error("ComputeNbrHash(maxDegree=14) and ComputeNbrHash(maxDegree=29) collided for identical (deg, neighbors); expected differing padLen to change the hash")
+	}
+}
+
+// TestNbrHashCollisionSampling is property (3): thousands of distinct,
+// randomly generated sorted neighbour sets per maxDegree must not collide.
+// randomNeighborSet can repeat the same (deg, neighbors) input across seeds
+// (most often the empty set at small maxDegree) -- those repeats are
+// expected to repeat their hash too, so only a hash collision between two
+// genuinely distinct inputs counts as a failure.
+func TestNbrHashCollisionSampling(t *testing.T) {
+	const samplesPerMaxDegree = 3000
+
+	for _, maxDegree := range boundaryMaxDegrees {
+		hashToInput := make(map[string]string, samplesPerMaxDegree)
+		for i := 0; i < samplesPerMaxDegree; i++ {
+			seed := int64(maxDegree)*1_000_000 + int64(i)
+			deg, neighbors := randomNeighborSet(seed, maxDegree)
+			input := fmt.Sprintf("%d:%v", deg, neighbors)
+			hash := ComputeNbrHash(deg, neighbors, maxDegree).String()
+
+			if prevInput, dup := hashToInput[hash]; dup && prevInput != input {
+				t.Fatalf("maxDegree=%d: collision at sample %d (seed %d): input %q and earlier input %q both hash to %s", maxDegree, i, seed, input, prevInput, hash)
+			}
+			hashToInput[hash] = input
+		}
+	}
+}
+
+// TestNbrHashSensitivity is property (4): flipping any single neighbour or
+// the degree changes the hash.
+func TestNbrHashSensitivity(t *testing.T) {
+	prop := func(seed int64, maxDegIdx uint8) bool {
+		maxDegree := boundaryMaxDegrees[int(maxDegIdx)%len(boundaryMaxDegrees)]
+		deg, neighbors := randomNeighborSet(seed, maxDegree)
+		if len(neighbors) == 0 {
+			return true // nothing to flip
+		}
+		base := ComputeNbrHash(deg, neighbors, maxDegree)
+
+		// Flip one neighbour (keeping it in range and distinct from its
+		// neighbours so the set stays strictly ascending where it matters
+		// to ValidateNeighbors, though ComputeNbrHash itself doesn't
+		// enforce ordering).
+		mutated := append([]uint64(nil), neighbors...)
+		mutated[len(mutated)-1] = mutated[len(mutated)-1] + 1
+		if ComputeNbrHash(deg, mutated, maxDegree).Cmp(base) == 0 {
+			return false
+		}
+
+		// Flip deg alone (same neighbours, different claimed degree).
+		if deg+1 <= maxDegree {
+			if ComputeNbrHash(deg+1, neighbors, maxDegree).Cmp(base) == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+// FuzzNbrHashDeterminism is the native-fuzzing counterpart of
+// TestNbrHashDeterminism, seeded at every PadLenFromMaxDegree boundary so
+// the corpus starts exactly where block-transition bugs would hide.
+func FuzzNbrHashDeterminism(f *testing.F) {
+	for i, maxDegree := range boundaryMaxDegrees {
+		f.Add(int64(maxDegree), uint8(i))
+	}
+
+	f.Fuzz(func(t *testing.T, seed int64, maxDegIdx uint8) {
+		maxDegree := boundaryMaxDegrees[int(maxDegIdx)%len(boundaryMaxDegrees)]
+		deg, neighbors := randomNeighborSet(seed, maxDegree)
+
+		h1 := ComputeNbrHash(deg, neighbors, maxDegree)
+		h2 := ComputeNbrHash(deg, neighbors, maxDegree)
+		if h1.Cmp(h2) != 0 {
+			t.Fatalf("ComputeNbrHash(deg=%d, maxDegree=%d) not deterministic: %s != %s", deg, maxDegree, h1.String(), h2.String())
+		}
+	})
+}
+
+// isStrictlyAscending reports whether neighbors is sorted with no
+// duplicates, i.e. the invariant ValidateNeighbors is meant to enforce.
+func isStrictlyAscending(neighbors []uint64) bool {
+	for i := 1; i < len(neighbors); i++ {
+		if neighbors[i] <= neighbors[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// FuzzValidateNeighborsRejectsUnsorted is property (5): arbitrary byte
+// soup, parsed into a uint64 neighbour list, is accepted by
+// ValidateNeighbors if and only if it is actually strictly ascending and
+// within maxDegree.
+func FuzzValidateNeighborsRejectsUnsorted(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}) // [1, 0]: not ascending
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 2}) // [1, 2]: ascending
+
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		const maxLen = 20 // keep each fuzz iteration fast
+		n := len(raw) / 8
+		if n > maxLen {
+			n = maxLen
+		}
+		neighbors := make([]uint64, n)
+		for i := 0; i < n; i++ {
+			neighbors[i] = binary.BigEndian.Uint64(raw[i*8 : i*8+8])
+		}
+
+		maxDegree := uint64(len(neighbors))
+		err := ValidateNeighbors(neighbors, maxDegree)
+		ascending := isStrictlyAscending(neighbors)
+
+		if ascending && err != nil {
+			t.Fatalf("ValidateNeighbors rejected a strictly ascending, in-bounds list %v: %v", neighbors, err)
+		}
+		if !ascending && err == nil {
+			t.Fatalf("ValidateNeighbors accepted a non-ascending list %v", neighbors)
+		}
+	})
+}
+
+// FuzzValidateNeighborsExceedsMaxDegree checks that any list longer than
+// maxDegree is always rejected, independent of ordering.
+func FuzzValidateNeighborsExceedsMaxDegree(f *testing.F) {
+	f.Add(uint8(15), uint8(14)) // exactly one over maxDegree
+	f.Add(uint8(60), uint8(59))
+
+	f.Fuzz(func(t *testing.T, degU8, maxDegU8 uint8) {
+		deg := uint64(degU8)
+		maxDegree := uint64(maxDegU8)
+		if deg <= maxDegree {
+			return // not the case this target is checking
+		}
+
+		neighbors := make([]uint64, deg)
+		for i := range neighbors {
+			neighbors[i] = uint64(i + 1)
+		}
+
+		if err := ValidateNeighbors(neighbors, maxDegree); err == nil {
+			t.Fatalf("ValidateNeighbors accepted degree %d exceeding maxDegree %d", deg, maxDegree)
+		}
+	})
+}
@@ -12,15 +12,11 @@ import (
 // ----------------------------
 
 // padLenFromMaxDegree returns padLen = 1 + 15 * ceil(maxDegree / 15).
-// This is the fixed neighbour-array length used in the circuit.
+// This is the fixed neighbour-array length used in the circuit. It is the
+// PoseidonBN254_16 special case of PadLenFor; kept as its own function
+// since it's the default backend and the one every existing caller expects.
 func PadLenFromMaxDegree(maxDegree uint64) int {
-	if maxDegree == 0 {
-		// deg=0 is allowed, but we still need room for the first block:
-		// [deg, 15 zeros] => length at least 16.
-		return 1 + 15
-	}
-	groups := (maxDegree + 14) / 15 // ceil(maxDeg / 15)
-	return int(1 + 15*groups)
+	return PadLenFor(PoseidonBN254_16, maxDegree)
 }
 
 // zeroArray forms an all-zero neighbour array of the correct padded length
@@ -54,6 +50,22 @@ func padNbrData(compact []uint64, maxDegree uint64) []uint64 {
 	return out
 }
 
+// ValidateNeighbors checks that an *unpadded* neighbour list is a legal
+// input to ComputeNbrHash: at most maxDegree entries, strictly ascending
+// (no duplicates, no zero sentinel mixed in), so that two different
+// neighbour lists can never be confused with one another once hashed.
+func ValidateNeighbors(neighbors []uint64, maxDegree uint64) error {
+	if uint64(len(neighbors)) > maxDegree {
+		return fmt.Errorf("ValidateNeighbors: degree %d exceeds maxDegree %d", len(neighbors), maxDegree)
+	}
+	for i := 1; i < len(neighbors); i++ {
+		if neighbors[i] <= neighbors[i-1] {
+			return fmt.Errorf("ValidateNeighbors: neighbors[%d]=%d does not exceed neighbors[%d]=%d; ids must be strictly ascending", i, neighbors[i], i-1, neighbors[i-1])
+		}
+	}
+	return nil
+}
+
 // ----------------------------
 // Poseidon-based neighbour hashing
 // ----------------------------